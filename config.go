@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// varnamd configurations
+// usually resides in $HOME/.varnamd/config.{json,toml,yaml} on POSIX and
+// LOCALAPPDATA/.varnamd/config.{json,toml,yaml} on Windows. The format is
+// auto-detected from the file extension; json, toml and yaml are all
+// accepted.
+type config struct {
+	Upstreams          []string          `json:"upstreams" mapstructure:"upstreams"`
+	SchemeUpstreams    map[string]string `json:"schemeUpstreams" mapstructure:"schemeUpstreams"` // per-scheme upstream override, tried before Upstreams
+	SchemesToSync      map[string]bool   `json:"schemesToSync" mapstructure:"schemesToSync"`
+	SyncIntervalInSecs time.Duration     `json:"syncIntervalInSecs" mapstructure:"syncIntervalInSecs"`
+	LogLevel           string            `json:"logLevel" mapstructure:"logLevel"`         // used when -log-level isn't passed on the command line
+	LogShipURL         string            `json:"logShipUrl" mapstructure:"logShipUrl"`     // upstream collector logs are shipped to; empty disables shipping
+	Telemetry          bool              `json:"telemetry" mapstructure:"telemetry"`       // opt-in; default false
+	TelemetryURL       string            `json:"telemetryUrl" mapstructure:"telemetryUrl"` // endpoint aggregate counters are POSTed to when Telemetry is true
+}
+
+// configMu guards varnamdConfig, which is replaced wholesale (rather than
+// mutated) whenever the config file changes on disk.
+var configMu sync.RWMutex
+
+func currentConfig() *config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return varnamdConfig
+}
+
+func setConfig(c *config) {
+	configMu.Lock()
+	varnamdConfig = c
+	configMu.Unlock()
+}
+
+func initDefaultConfig() *config {
+	c := &config{}
+	c.setDefaultsForBlankValues()
+	return c
+}
+
+func (c *config) setDefaultsForBlankValues() {
+	if len(c.Upstreams) == 0 {
+		c.Upstreams = []string{"http://api.varnamproject.com"}
+	}
+	if c.SchemeUpstreams == nil {
+		c.SchemeUpstreams = make(map[string]string)
+	}
+	if c.SchemesToSync == nil {
+		c.SchemesToSync = make(map[string]bool)
+	}
+	if c.SyncIntervalInSecs == 0 {
+		c.SyncIntervalInSecs = 30
+	}
+}
+
+func getConfigDir() string {
+	if runtime.GOOS == "windows" {
+		return path.Join(os.Getenv("localappdata"), ".varnamd")
+	}
+	return path.Join(os.Getenv("HOME"), ".varnamd")
+}
+
+// getConfigFilePath returns the -config flag value if one was given,
+// otherwise the default json path under getConfigDir().
+func getConfigFilePath() string {
+	if configPath != "" {
+		return configPath
+	}
+	return path.Join(getConfigDir(), "config.json")
+}
+
+func configFormat(configFilePath string) string {
+	switch filepath.Ext(configFilePath) {
+	case ".toml":
+		return "toml"
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "json"
+	}
+}
+
+func loadConfigFromFile() *config {
+	configFilePath := getConfigFilePath()
+
+	v := viper.New()
+	v.SetConfigFile(configFilePath)
+	v.SetConfigType(configFormat(configFilePath))
+	if err := v.ReadInConfig(); err != nil {
+		if c := migrateLegacyJSONConfig(configFilePath); c != nil {
+			return c
+		}
+		c := initDefaultConfig()
+		c.save()
+		return initDefaultConfig()
+	}
+
+	var c config
+	if err := v.Unmarshal(&c); err != nil {
+		log.Printf("%s is malformed. Using default config instead\n", configFilePath)
+		return initDefaultConfig()
+	}
+
+	c.setDefaultsForBlankValues()
+	return &c
+}
+
+// migrateLegacyJSONConfig reads the old $HOME/.varnamd/config.json file,
+// if present, and rewrites it in configFilePath's format. It returns nil
+// if there's nothing to migrate.
+func migrateLegacyJSONConfig(configFilePath string) *config {
+	if configFormat(configFilePath) == "json" {
+		return nil
+	}
+
+	legacyPath := path.Join(getConfigDir(), "config.json")
+	f, err := os.Open(legacyPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var c config
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return nil
+	}
+
+	c.setDefaultsForBlankValues()
+	c.save()
+	return &c
+}
+
+func (c *config) setSyncStatus(langCode string, status bool) {
+	c.SchemesToSync[langCode] = status
+}
+
+func (c *config) save() error {
+	configFilePath := getConfigFilePath()
+	if err := os.MkdirAll(path.Dir(configFilePath), 0777); err != nil {
+		return err
+	}
+
+	v := viper.New()
+	v.SetConfigType(configFormat(configFilePath))
+	v.Set("upstreams", c.Upstreams)
+	v.Set("schemeUpstreams", c.SchemeUpstreams)
+	v.Set("schemesToSync", c.SchemesToSync)
+	v.Set("syncIntervalInSecs", c.SyncIntervalInSecs)
+	v.Set("logLevel", c.LogLevel)
+	v.Set("logShipUrl", c.LogShipURL)
+	v.Set("telemetry", c.Telemetry)
+	v.Set("telemetryUrl", c.TelemetryURL)
+
+	return v.WriteConfigAs(configFilePath)
+}
+
+// reloadConfig re-reads the config file from disk and swaps it in,
+// notifying sync so its interval and upstream list pick up changes
+// immediately. Used by both watchConfigFile and the SIGHUP handler.
+// reloadConfig tolerates a nil sync: -sync-words=false still gets config
+// hot-reload, it just has no dispatcher to notify of the change.
+func reloadConfig(sync *syncDispatcher) {
+	c := loadConfigFromFile()
+	if len(upstreamFlags) > 0 {
+		c.Upstreams = upstreamFlags
+	}
+	setConfig(c)
+	if sync == nil {
+		return
+	}
+	sync.reload(c.SyncIntervalInSecs * time.Second)
+	sync.syncUpstreamList()
+}
+
+// watchConfigFile re-reads the config file on every change and swaps
+// varnamdConfig behind configMu, so changes to SyncIntervalInSecs and
+// SchemesToSync take effect without restarting varnamd. sync is notified
+// so its ticker picks up a new SyncIntervalInSecs immediately.
+func watchConfigFile(sync *syncDispatcher) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		appLogger.Error().Err(err).Msg("config watcher unavailable")
+		return
+	}
+	defer watcher.Close()
+
+	configFilePath := getConfigFilePath()
+	if err := watcher.Add(path.Dir(configFilePath)); err != nil {
+		appLogger.Error().Err(err).Msg("config watcher unavailable")
+		return
+	}
+
+	for event := range watcher.Events {
+		if filepath.Clean(event.Name) != filepath.Clean(configFilePath) {
+			continue
+		}
+		if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+			continue
+		}
+
+		reloadConfig(sync)
+		appLogger.Info().Msg("config reloaded")
+	}
+}
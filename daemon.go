@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mufeedvh/varnamd-govarnam/internal/logging"
+	"github.com/sevlyar/go-daemon"
+)
+
+func getPidFilePath() string {
+	return path.Join(getConfigDir(), "varnamd.pid")
+}
+
+func writePidFile() error {
+	return os.WriteFile(getPidFilePath(), []byte(strconv.Itoa(os.Getpid())), 0644)
+}
+
+func readPidFile() (int, error) {
+	b, err := os.ReadFile(getPidFilePath())
+	if err != nil {
+		return 0, fmt.Errorf("reading pid file: %w", err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// signalRunningDaemon sends sig to the process recorded in the PID file,
+// for the `varnamd stop`/`varnamd reload` subcommands.
+func signalRunningDaemon(sig syscall.Signal) error {
+	pid, err := readPidFile()
+	if err != nil {
+		return err
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	return process.Signal(sig)
+}
+
+// runSubcommand handles `varnamd stop` and `varnamd reload`, which
+// signal an already-running daemon instead of starting a new one. It
+// returns true when args named a recognised subcommand and the signal was
+// sent; if sending the signal fails it prints the error and exits with
+// status 1.
+func runSubcommand(args []string) bool {
+	if len(args) < 2 {
+		return false
+	}
+
+	var sig syscall.Signal
+	switch args[1] {
+	case "stop":
+		sig = syscall.SIGTERM
+	case "reload":
+		sig = syscall.SIGHUP
+	default:
+		return false
+	}
+
+	if err := signalRunningDaemon(sig); err != nil {
+		fmt.Fprintf(os.Stderr, "varnamd: %v\n", err)
+		os.Exit(1)
+	}
+
+	return true
+}
+
+// daemonize forks the process into the background via go-daemon. The
+// parent returns with exit set to true and should os.Exit(0); the child
+// continues running with exit set to false.
+func daemonize() (exit bool, err error) {
+	ctx := &daemon.Context{
+		LogFileName: path.Join(getLogsDir(), "daemon.log"),
+		LogFilePerm: 0640,
+		WorkDir:     "./",
+	}
+
+	child, err := ctx.Reborn()
+	if err != nil {
+		return false, fmt.Errorf("forking daemon: %w", err)
+	}
+	if child != nil {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// waitForShutdown blocks handling signals until SIGTERM or SIGINT asks
+// the daemon to exit: it drains in-flight HTTP requests via srv.Shutdown,
+// flushes the sync queue and removes the PID file before returning.
+// SIGHUP reloads the config from disk instead of exiting.
+func waitForShutdown(logger *logging.Logger, srv *http.Server, sync *syncDispatcher) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer os.Remove(getPidFilePath())
+
+	for sig := range sigCh {
+		if sig == syscall.SIGHUP {
+			logger.Info().Msg("reloading config on SIGHUP")
+			reloadConfig(sync)
+			continue
+		}
+
+		logger.Info().Str("signal", sig.String()).Msg("shutting down")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error().Err(err).Msg("error during graceful shutdown")
+		}
+		cancel()
+
+		if sync != nil {
+			sync.stopDispatcher()
+		}
+		if err := logger.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "varnamd: failed to flush logger: %v\n", err)
+		}
+		return
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mufeedvh/varnamd-govarnam/internal/logging"
+)
+
+// metaResponse is served at /meta: the installation's identity and
+// opt-in state, for operators and support tooling to introspect.
+type metaResponse struct {
+	InstanceID string `json:"instanceId"`
+	Telemetry  bool   `json:"telemetry"`
+	Version    string `json:"version"`
+}
+
+// startDaemon builds the varnamd HTTP server and starts it in the
+// background, returning immediately so the caller can install signal
+// handlers for graceful shutdown. sync may be nil when -sync-words=false;
+// the /sync/status internal API is only registered when it's available.
+func startDaemon(logger *logging.Logger, sync *syncDispatcher, inst *instance) *http.Server {
+	mux := http.NewServeMux()
+
+	if enableInternalApis && sync != nil {
+		mux.HandleFunc("/sync/status", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(sync.status())
+		})
+	}
+
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(metaResponse{
+			InstanceID: inst.ID,
+			Telemetry:  currentConfig().Telemetry,
+			Version:    VERSION,
+		})
+	})
+
+	// The govarnam-backed transliterate/learn handlers live outside this
+	// snapshot of the tree. counters.recordTransliteration/recordWordLearned
+	// belong in those handlers, once they exist, not in placeholder routes
+	// here - wiring them to a stub only fakes telemetry for traffic that
+	// was never actually served.
+
+	addr := fmt.Sprintf("%s:%d", host, port)
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	logger.Info().Str("addr", addr).Msg("starting varnamd")
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error().Err(err).Msg("daemon exited")
+		}
+	}()
+
+	return srv
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// instance is varnamd's persistent per-installation identity: generated
+// once on first run and reused across restarts, similar to how logpolicy
+// caches a stable PrivateID on disk. Upstreams use it to de-dupe learned
+// words per-node.
+type instance struct {
+	ID        string    `json:"id"`
+	FirstSeen time.Time `json:"firstSeen"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+}
+
+func getInstanceFilePath() string {
+	return path.Join(getConfigDir(), "instance.json")
+}
+
+var (
+	instanceOnce sync.Once
+	thisInstance *instance
+)
+
+// loadInstance returns this installation's identity, creating and
+// persisting a new one on first run.
+func loadInstance() *instance {
+	instanceOnce.Do(func() {
+		thisInstance = readOrCreateInstance()
+	})
+	return thisInstance
+}
+
+func readOrCreateInstance() *instance {
+	if f, err := os.Open(getInstanceFilePath()); err == nil {
+		defer f.Close()
+		var inst instance
+		if err := json.NewDecoder(f).Decode(&inst); err == nil {
+			return &inst
+		}
+	}
+
+	inst := &instance{
+		ID:        uuid.NewString(),
+		FirstSeen: time.Now(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	inst.save()
+	return inst
+}
+
+func (i *instance) save() error {
+	if err := os.MkdirAll(path.Dir(getInstanceFilePath()), 0777); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(i, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(getInstanceFilePath(), b, 0644)
+}
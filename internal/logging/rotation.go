@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// rollingWriter is an io.Writer that writes to a date-named log file and
+// rolls over to a new one the first time a write happens on a new
+// calendar day, gzip-compressing the file it rolled away from.
+type rollingWriter struct {
+	mu            sync.Mutex
+	dir           string
+	retentionDays int
+	current       *os.File
+	currentDate   string
+}
+
+func newRollingWriter(dir string, retentionDays int) (*rollingWriter, error) {
+	w := &rollingWriter{dir: dir, retentionDays: retentionDays}
+	if err := w.rotateLocked(logFileName(time.Now())); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func logFileName(t time.Time) string {
+	year, month, day := t.Date()
+	return fmt.Sprintf("%d-%d-%d.log", year, month, day)
+}
+
+func (w *rollingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if today := logFileName(time.Now()); today != w.currentDate {
+		if err := w.rotateLocked(today); err != nil {
+			return 0, err
+		}
+	}
+
+	return w.current.Write(p)
+}
+
+// rotateLocked switches the active file to today. Callers must hold w.mu.
+func (w *rollingWriter) rotateLocked(today string) error {
+	f, err := os.OpenFile(filepath.Join(w.dir, today), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("logging: opening %s: %w", today, err)
+	}
+
+	previous := w.current
+	w.current = f
+	w.currentDate = today
+
+	if previous != nil {
+		go compressAndClose(previous)
+	}
+	if w.retentionDays > 0 {
+		go prune(w.dir, w.retentionDays)
+	}
+
+	return nil
+}
+
+func (w *rollingWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current == nil {
+		return nil
+	}
+	return w.current.Close()
+}
+
+// compressAndClose gzips the file f was writing to, removes the
+// uncompressed copy and closes f.
+func compressAndClose(f *os.File) {
+	defer f.Close()
+
+	path := f.Name()
+	src, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return
+	}
+	defer dst.Close()
+
+	gw := gzip.NewWriter(dst)
+	if _, err := io.Copy(gw, src); err != nil {
+		gw.Close()
+		return
+	}
+	if err := gw.Close(); err != nil {
+		return
+	}
+
+	os.Remove(path)
+}
+
+// prune removes compressed log files older than retentionDays.
+func prune(dir string, retentionDays int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".gz" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
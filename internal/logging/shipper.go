@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// shipper batches written log lines and POSTs them, zstd-compressed, to
+// an upstream collector on a fixed interval, retrying with backoff so a
+// flaky collector never blocks logging itself.
+type shipper struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	pending [][]byte
+
+	flushEvery time.Duration
+	stop       chan struct{}
+	done       chan struct{} // closed once loop has made its final flush
+}
+
+func newShipper(url string) *shipper {
+	s := &shipper{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		flushEvery: 5 * time.Second,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// Write implements io.Writer so a shipper can be plugged into zerolog's
+// MultiLevelWriter alongside the rolling file writer.
+func (s *shipper) Write(p []byte) (int, error) {
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	s.mu.Lock()
+	s.pending = append(s.pending, line)
+	s.mu.Unlock()
+
+	return len(p), nil
+}
+
+func (s *shipper) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *shipper) close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *shipper) flush() {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	body := bytes.Join(batch, []byte("\n"))
+
+	backoff := time.Second
+	for attempt := 0; attempt < 3; attempt++ {
+		if err := s.send(body); err == nil {
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *shipper) send(body []byte) error {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(enc.EncodeAll(body, nil)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "zstd")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("logging: shipper got status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path"
 	"runtime"
+	"strings"
 	"time"
+
+	"github.com/mufeedvh/varnamd-govarnam/internal/logging"
 )
 
 var (
@@ -20,42 +22,38 @@ var (
 	enableInternalApis bool    // internal APIs are not exposed to public
 	syncWords          bool    // when true, sync won't be performed. Useful when running on a top level server where no upstream can be configured
 	logToFile          bool    // logs will be written to file when true
-	varnamdConfig      *config // config instance used across the application
+	logLevel           string  // minimum level emitted by appLogger
+	configPath         string  // -config override; empty means the default path under getConfigDir()
+	upstreamFlags      upstreamList
+	runAsDaemon        bool // -daemon: fork into the background
+	varnamdConfig      *config // config instance used across the application, see config.go
 	startedAt          time.Time
+	appLogger          *logging.Logger // logger injected into startDaemon and syncDispatcher
 )
 
-// varnamd configurations
-// usually resides in $HOME/.varnamd/config on POSIX and APPDATA/.varnamd/config on Windows
-type config struct {
-	Upstream           string          `json:"upstream"`
-	SchemesToSync      map[string]bool `json:"schemesToSync"`
-	SyncIntervalInSecs time.Duration   `json:syncIntervalInSecs`
-}
+// upstreamList collects repeated -upstream flag values, in the order
+// given on the command line.
+type upstreamList []string
 
-func initDefaultConfig() *config {
-	c := &config{}
-	c.setDefaultsForBlankValues()
-	return c
+func (u *upstreamList) String() string {
+	return strings.Join(*u, ",")
 }
 
-func (c *config) setDefaultsForBlankValues() {
-	if c.Upstream == "" {
-		c.Upstream = "http://api.varnamproject.com"
-	}
-	if c.SchemesToSync == nil {
-		c.SchemesToSync = make(map[string]bool)
-	}
-	if c.SyncIntervalInSecs == 0 {
-		c.SyncIntervalInSecs = 30
-	}
+func (u *upstreamList) Set(value string) error {
+	*u = append(*u, value)
+	return nil
 }
 
-func getConfigDir() string {
-	if runtime.GOOS == "windows" {
-		return path.Join(os.Getenv("localappdata"), ".varnamd")
-	} else {
-		return path.Join(os.Getenv("HOME"), ".varnamd")
-	}
+// isFlagSet reports whether name was explicitly passed on the command
+// line, as opposed to merely holding its zero/default value.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 func getLogsDir() string {
@@ -69,74 +67,6 @@ func getLogsDir() string {
 	return logsDir
 }
 
-func getConfigFilePath() string {
-	configDir := getConfigDir()
-	configFilePath := path.Join(configDir, "config.json")
-	return configFilePath
-}
-
-func loadConfigFromFile() *config {
-	configFilePath := getConfigFilePath()
-	configFile, err := os.Open(configFilePath)
-	if err != nil {
-		c := initDefaultConfig()
-		c.save()
-		return initDefaultConfig()
-	}
-	defer configFile.Close()
-
-	jsonDecoder := json.NewDecoder(configFile)
-	var c config
-	err = jsonDecoder.Decode(&c)
-	if err != nil {
-		log.Printf("%s is malformed. Using default config instead\n", configFilePath)
-		return initDefaultConfig()
-	}
-
-	c.setDefaultsForBlankValues()
-	return &c
-}
-
-func (c *config) setSyncStatus(langCode string, status bool) {
-	c.SchemesToSync[langCode] = status
-}
-
-func (c *config) save() error {
-	configFilePath := getConfigFilePath()
-	err := os.MkdirAll(path.Dir(configFilePath), 0777)
-	if err != nil {
-		return err
-	}
-
-	configFile, err := os.Create(configFilePath)
-	if err != nil {
-		return err
-	}
-	defer configFile.Close()
-
-	b, err := json.MarshalIndent(c, "", "\t")
-	if err != nil {
-		return err
-	}
-
-	_, err = configFile.Write(b)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func redirectLogToFile() {
-	year, month, day := time.Now().Date()
-	logfile := path.Join(getLogsDir(), fmt.Sprintf("%d-%d-%d.log", year, month, day))
-	f, err := os.OpenFile(logfile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		panic(err)
-	}
-	log.SetOutput(f)
-}
-
 func init() {
 	flag.IntVar(&port, "p", 8080, "Run daemon in specified port")
 	flag.IntVar(&maxHandleCount, "max-handle-count", 10, "Maximum number of handles can be opened for each language")
@@ -145,25 +75,74 @@ func init() {
 	flag.BoolVar(&enableInternalApis, "enable-internal-apis", false, "Enable internal APIs")
 	flag.BoolVar(&syncWords, "sync-words", true, "Enable/Disable word synchronization")
 	flag.BoolVar(&logToFile, "log-to-file", false, "If true, logs will be written to a file")
+	flag.StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	flag.StringVar(&configPath, "config", "", "Path to config file (json, toml or yaml); defaults to $HOME/.varnamd/config.json")
+	flag.Var(&upstreamFlags, "upstream", "Upstream to sync with; repeat for failover, overrides the config file's upstreams")
+	flag.BoolVar(&runAsDaemon, "daemon", false, "Fork varnamd into the background")
 	flag.BoolVar(&version, "version", false, "Print the version and exit")
-	varnamdConfig = loadConfigFromFile()
 }
 
 func main() {
+	if runSubcommand(os.Args) {
+		return
+	}
+
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	flag.Parse()
+	varnamdConfig = loadConfigFromFile()
+	if len(upstreamFlags) > 0 {
+		varnamdConfig.Upstreams = upstreamFlags
+	}
 	startedAt = time.Now()
 	if version {
 		fmt.Println(VERSION)
 		os.Exit(0)
 	}
+
+	if runAsDaemon {
+		exit, err := daemonize()
+		if err != nil {
+			log.Fatalf("failed to daemonize: %v", err)
+		}
+		if exit {
+			os.Exit(0)
+		}
+	}
+	if err := writePidFile(); err != nil {
+		log.Fatalf("failed to write pid file: %v", err)
+	}
+
+	// -log-level on the command line wins; otherwise fall back to the
+	// config file's logLevel, keeping the flag's "info" default as the
+	// last resort.
+	if !isFlagSet("log-level") && varnamdConfig.LogLevel != "" {
+		logLevel = varnamdConfig.LogLevel
+	}
 	if logToFile {
-		redirectLogToFile()
+		l, err := logging.New(logging.Config{
+			Level:         logging.ParseLevel(logLevel),
+			Dir:           getLogsDir(),
+			RetentionDays: 14,
+			ShipURL:       varnamdConfig.LogShipURL,
+		})
+		if err != nil {
+			log.Fatalf("failed to initialize logger: %v", err)
+		}
+		appLogger = l
+	} else {
+		appLogger = logging.NewConsole(logging.ParseLevel(logLevel))
 	}
+
+	inst := loadInstance()
+	startTelemetryReporter(appLogger, inst)
+
+	var sync *syncDispatcher
 	if syncWords {
-		sync := newSyncDispatcher(varnamdConfig.SyncIntervalInSecs * time.Second)
+		sync = newSyncDispatcher(appLogger, varnamdConfig.SyncIntervalInSecs*time.Second, inst.ID)
 		sync.start()
 		sync.runNow() // Run immediatly when starting varnamd
 	}
-	startDaemon()
+	go watchConfigFile(sync) // hot-reloads varnamdConfig even when sync is nil
+	srv := startDaemon(appLogger, sync, inst)
+	waitForShutdown(appLogger, srv, sync)
 }
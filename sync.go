@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mufeedvh/varnamd-govarnam/internal/logging"
+)
+
+// upstreamHealth tracks the failover state of a single upstream endpoint.
+type upstreamHealth struct {
+	URL                 string    `json:"url"`
+	Healthy             bool      `json:"healthy"`
+	LastSuccess         time.Time `json:"lastSuccess,omitempty"`
+	LastError           string    `json:"lastError,omitempty"`
+	CooldownUntil       time.Time `json:"cooldownUntil,omitempty"`
+	consecutiveFailures int
+}
+
+// schemeLag records how long ago a scheme last synced successfully.
+type schemeLag struct {
+	LastSyncedAt time.Time `json:"lastSyncedAt,omitempty"`
+}
+
+// syncStatus is the JSON payload served at /sync/status.
+type syncStatus struct {
+	Upstreams map[string]upstreamHealth `json:"upstreams"`
+	Schemes   map[string]schemeLag      `json:"schemes"`
+}
+
+// syncDispatcher periodically pushes newly learned words, for every
+// scheme enabled in SchemesToSync, to the configured upstream(s). Each
+// scheme tries its SchemeUpstreams override first, then Upstreams in
+// order, skipping endpoints that are in their failure cooldown.
+type syncDispatcher struct {
+	interval   time.Duration
+	instanceID string // tags outgoing sync requests via X-Varnamd-Instance
+	logger     *logging.Logger
+	client     *http.Client
+
+	mu           sync.Mutex
+	upstreams    map[string]*upstreamHealth
+	schemeLag    map[string]*schemeLag
+	pendingWords map[string][]string // words learned since the last successful sync, by scheme
+
+	ticker *time.Ticker
+	stop   chan struct{}
+}
+
+func newSyncDispatcher(logger *logging.Logger, interval time.Duration, instanceID string) *syncDispatcher {
+	s := &syncDispatcher{
+		interval:     interval,
+		instanceID:   instanceID,
+		logger:       logger,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		upstreams:    make(map[string]*upstreamHealth),
+		schemeLag:    make(map[string]*schemeLag),
+		pendingWords: make(map[string][]string),
+		stop:         make(chan struct{}),
+	}
+	s.syncUpstreamList()
+	return s
+}
+
+// syncUpstreamList reconciles the tracked upstream set with the current
+// config's Upstreams and SchemeUpstreams, adding newly configured
+// endpoints and dropping removed ones. Safe to call again after a hot
+// config reload.
+func (s *syncDispatcher) syncUpstreamList() {
+	cfg := currentConfig()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(cfg.Upstreams))
+	add := func(u string) {
+		seen[u] = true
+		if _, ok := s.upstreams[u]; !ok {
+			s.upstreams[u] = &upstreamHealth{URL: u, Healthy: true}
+		}
+	}
+	for _, u := range cfg.Upstreams {
+		add(u)
+	}
+	for _, u := range cfg.SchemeUpstreams {
+		add(u)
+	}
+	for u := range s.upstreams {
+		if !seen[u] {
+			delete(s.upstreams, u)
+		}
+	}
+}
+
+func (s *syncDispatcher) start() {
+	s.ticker = time.NewTicker(s.interval)
+	go func() {
+		for {
+			select {
+			case <-s.ticker.C:
+				s.runNow()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// reload swaps in a new sync interval, resetting the ticker so it takes
+// effect immediately instead of waiting for the current period to elapse.
+// Called by watchConfigFile when the config file changes on disk.
+func (s *syncDispatcher) reload(interval time.Duration) {
+	s.interval = interval
+	if s.ticker != nil {
+		s.ticker.Reset(interval)
+	}
+}
+
+// stopDispatcher runs one last sync to flush anything pending, then
+// stops the ticker goroutine. Called during graceful shutdown.
+func (s *syncDispatcher) stopDispatcher() {
+	s.runNow()
+	if s.ticker != nil {
+		s.ticker.Stop()
+	}
+	close(s.stop)
+}
+
+// runNow triggers an out-of-band sync of every enabled scheme
+// immediately, in addition to the regular interval-driven runs.
+func (s *syncDispatcher) runNow() {
+	cfg := currentConfig()
+	for scheme, enabled := range cfg.SchemesToSync {
+		if enabled {
+			s.syncScheme(cfg, scheme)
+		}
+	}
+}
+
+func (s *syncDispatcher) syncScheme(cfg *config, scheme string) {
+	words := s.pendingWordsSnapshot(scheme)
+	if len(words) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(words)
+	if err != nil {
+		s.logger.Error().Str("scheme", scheme).Err(err).Msg("failed to encode pending words")
+		counters.recordError()
+		return
+	}
+
+	for _, upstream := range s.orderedUpstreams(cfg, scheme) {
+		if err := s.sendToUpstream(upstream, scheme, body); err != nil {
+			s.logger.Warn().Str("upstream", upstream).Str("scheme", scheme).Err(err).Msg("sync failed, trying next upstream")
+			continue
+		}
+		s.markSchemeSynced(scheme)
+		s.clearPendingWords(scheme, len(words))
+		return
+	}
+	s.logger.Error().Str("scheme", scheme).Msg("sync failed on every upstream")
+	counters.recordError()
+}
+
+// queueLearnedWord records word as pending sync for scheme; the next
+// sync cycle sends it upstream as part of that scheme's batch.
+func (s *syncDispatcher) queueLearnedWord(scheme, word string) {
+	s.mu.Lock()
+	s.pendingWords[scheme] = append(s.pendingWords[scheme], word)
+	s.mu.Unlock()
+}
+
+// pendingWordsSnapshot returns a copy of the words queued for scheme
+// since the last successful sync, without clearing them - the queue is
+// only drained once the send actually succeeds.
+func (s *syncDispatcher) pendingWordsSnapshot(scheme string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.pendingWords[scheme]...)
+}
+
+// clearPendingWords drops the first n words queued for scheme, leaving
+// anything queued after the snapshot that fed the successful send.
+func (s *syncDispatcher) clearPendingWords(scheme string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pendingWords[scheme]) <= n {
+		delete(s.pendingWords, scheme)
+		return
+	}
+	s.pendingWords[scheme] = s.pendingWords[scheme][n:]
+}
+
+// orderedUpstreams returns the upstreams to try for scheme, in priority
+// order: the scheme-specific override first (if configured), then the
+// global Upstreams list, deduplicated and skipping endpoints still in
+// their cooldown window.
+func (s *syncDispatcher) orderedUpstreams(cfg *config, scheme string) []string {
+	var ordered []string
+	if override, ok := cfg.SchemeUpstreams[scheme]; ok {
+		ordered = append(ordered, override)
+	}
+	ordered = append(ordered, cfg.Upstreams...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	seen := make(map[string]bool, len(ordered))
+	usable := make([]string, 0, len(ordered))
+	for _, u := range ordered {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		if h, ok := s.upstreams[u]; ok && !h.Healthy && now.Before(h.CooldownUntil) {
+			continue
+		}
+		usable = append(usable, u)
+	}
+	return usable
+}
+
+func (s *syncDispatcher) sendToUpstream(upstream, scheme string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, upstream+"/learn/"+scheme, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Varnamd-Instance", s.instanceID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.recordFailure(upstream, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("upstream returned status %d", resp.StatusCode)
+		s.recordFailure(upstream, err)
+		return err
+	}
+
+	s.recordSuccess(upstream)
+	return nil
+}
+
+// recordFailure marks upstream unhealthy and puts it in an exponentially
+// growing cooldown (capped at a minute) before it's tried again.
+func (s *syncDispatcher) recordFailure(upstream string, cause error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.healthLocked(upstream)
+	h.Healthy = false
+	h.LastError = cause.Error()
+	h.consecutiveFailures++
+
+	exp := h.consecutiveFailures
+	if exp > 6 {
+		exp = 6
+	}
+	backoff := time.Second * time.Duration(math.Pow(2, float64(exp)))
+	if backoff > time.Minute {
+		backoff = time.Minute
+	}
+	h.CooldownUntil = time.Now().Add(backoff)
+}
+
+func (s *syncDispatcher) recordSuccess(upstream string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.healthLocked(upstream)
+	h.Healthy = true
+	h.consecutiveFailures = 0
+	h.LastError = ""
+	h.LastSuccess = time.Now()
+}
+
+// healthLocked returns upstream's tracked health, creating it if this is
+// the first time it's been seen. Callers must hold s.mu.
+func (s *syncDispatcher) healthLocked(upstream string) *upstreamHealth {
+	h, ok := s.upstreams[upstream]
+	if !ok {
+		h = &upstreamHealth{URL: upstream}
+		s.upstreams[upstream] = h
+	}
+	return h
+}
+
+func (s *syncDispatcher) markSchemeSynced(scheme string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.schemeLag[scheme]
+	if !ok {
+		l = &schemeLag{}
+		s.schemeLag[scheme] = l
+	}
+	l.LastSyncedAt = time.Now()
+}
+
+// status reports per-upstream health and per-scheme sync lag, served at
+// the /sync/status internal API.
+func (s *syncDispatcher) status() syncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := syncStatus{
+		Upstreams: make(map[string]upstreamHealth, len(s.upstreams)),
+		Schemes:   make(map[string]schemeLag, len(s.schemeLag)),
+	}
+	for k, v := range s.upstreams {
+		st.Upstreams[k] = *v
+	}
+	for k, v := range s.schemeLag {
+		st.Schemes[k] = *v
+	}
+	return st
+}
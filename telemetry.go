@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mufeedvh/varnamd-govarnam/internal/logging"
+)
+
+// telemetryCounters accumulates the aggregate counters reported by the
+// telemetry reporter. All fields are reset to zero once reported, so
+// each report covers only the interval since the last one.
+type telemetryCounters struct {
+	mu                sync.Mutex
+	wordsLearned      int64
+	errors            int64
+	translitsByScheme map[string]int64
+}
+
+var counters = &telemetryCounters{translitsByScheme: make(map[string]int64)}
+
+func (c *telemetryCounters) recordWordLearned() {
+	c.mu.Lock()
+	c.wordsLearned++
+	c.mu.Unlock()
+}
+
+func (c *telemetryCounters) recordTransliteration(scheme string) {
+	c.mu.Lock()
+	c.translitsByScheme[scheme]++
+	c.mu.Unlock()
+}
+
+func (c *telemetryCounters) recordError() {
+	c.mu.Lock()
+	c.errors++
+	c.mu.Unlock()
+}
+
+// telemetryReport is the JSON payload POSTed to the configured telemetry
+// endpoint.
+type telemetryReport struct {
+	InstanceID               string           `json:"instanceId"`
+	WordsLearned             int64            `json:"wordsLearned"`
+	TransliterationsByScheme map[string]int64 `json:"transliterationsByScheme"`
+	Errors                   int64            `json:"errors"`
+}
+
+func (c *telemetryCounters) snapshotAndReset(instanceID string) telemetryReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := telemetryReport{
+		InstanceID:               instanceID,
+		WordsLearned:             c.wordsLearned,
+		TransliterationsByScheme: c.translitsByScheme,
+		Errors:                   c.errors,
+	}
+
+	c.wordsLearned = 0
+	c.errors = 0
+	c.translitsByScheme = make(map[string]int64)
+
+	return report
+}
+
+// startTelemetryReporter runs for the lifetime of the process, POSTing a
+// counters snapshot to the configured telemetry endpoint every hour. It's
+// a no-op unless telemetry is opted into and an endpoint is configured.
+func startTelemetryReporter(logger *logging.Logger, inst *instance) {
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		for range ticker.C {
+			cfg := currentConfig()
+			if !cfg.Telemetry || cfg.TelemetryURL == "" {
+				continue
+			}
+
+			report := counters.snapshotAndReset(inst.ID)
+			if err := postTelemetry(cfg.TelemetryURL, report); err != nil {
+				logger.Warn().Err(err).Msg("telemetry report failed")
+			}
+		}
+	}()
+}
+
+func postTelemetry(url string, report telemetryReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}